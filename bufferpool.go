@@ -0,0 +1,56 @@
+package msgio
+
+import (
+	"io"
+
+	multipool "github.com/jbenet/go-msgio/multipool"
+)
+
+// BufferPool is a minimal buffer-pooling interface. It lets a Reader be
+// backed by any pool an application already maintains — for example
+// github.com/libp2p/go-buffer-pool's power-of-two pool, whose *pool.Pool
+// satisfies this interface as-is, or a sync.Pool wrapped to match it —
+// instead of forcing every caller onto msgio's own multipool.Pool.
+type BufferPool interface {
+	// Get returns a buffer of at least length bytes.
+	Get(length int) []byte
+	// Put returns a buffer obtained from Get back to the pool.
+	Put([]byte)
+}
+
+// multipoolAdapter adapts a *multipool.Pool, msgio's original pool type, to
+// the BufferPool interface, so reader only ever has to speak BufferPool.
+type multipoolAdapter struct {
+	pool *multipool.Pool
+}
+
+func (a multipoolAdapter) Get(length int) []byte {
+	b := a.pool.Get(uint32(length))
+	if b == nil {
+		return nil
+	}
+	return b.([]byte)[:length]
+}
+
+func (a multipoolAdapter) Put(b []byte) {
+	c := cap(b)
+	if c > multipool.MaxLength {
+		c = multipool.MaxLength
+	}
+	a.pool.Put(uint32(c), b)
+}
+
+// NewReaderWithBufferPool is like NewReaderWithPool, but takes any
+// BufferPool implementation instead of requiring msgio's own
+// multipool.Pool. This lets an application plug in a pool it already
+// maintains rather than double-pooling around this package.
+func NewReaderWithBufferPool(r io.Reader, p BufferPool, opts ...ReaderOpt) ReadCloser {
+	if p == nil {
+		panic("nil pool")
+	}
+	var o readerOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &reader{r, make([]byte, lengthSize), -1, p, o.maxMsgLen}
+}
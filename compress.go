@@ -0,0 +1,257 @@
+package msgio
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	multipool "github.com/jbenet/go-msgio/multipool"
+)
+
+// flateFinalBlock is the 4-byte tail of the empty (non-final) stored block
+// that flate.Writer.Flush leaves at the end of a sync-flushed stream.
+// Per-message framing strips it on write and re-appends it on read (the same
+// trick gorilla/websocket uses for its no-context-takeover permessage-deflate
+// mode) so flate.Reader can decode the whole message without the two sides
+// sharing any state; since the block is not marked final, the reader then
+// reports io.ErrUnexpectedEOF, which inflate treats as the end of the message.
+var flateFinalBlock = []byte{0x00, 0x00, 0xff, 0xff}
+
+// maxFlateDict is the size of the flate sliding window. A context-takeover
+// dictionary longer than this contributes nothing more to the compressor,
+// so it's the most either side ever needs to retain between messages.
+const maxFlateDict = 32768
+
+// CompressionOpts configures a compressed Reader/Writer pair.
+type CompressionOpts struct {
+	// Level is passed to compress/flate. It is flate.DefaultCompression only
+	// when no CompressionOpts is supplied; a zero Level is flate.NoCompression.
+	Level int
+
+	// ContextTakeover keeps the flate dictionary alive across messages
+	// instead of resetting it for every frame (gorilla/websocket's
+	// "permessage-deflate" context-takeover mode). This compresses better
+	// for chatty peers that have negotiated it, but requires messages to be
+	// read/written in order with none skipped, since the dictionary from
+	// message N is required to decode message N+1.
+	ContextTakeover bool
+}
+
+// DefaultCompressionOpts is used by NewCompressedReader/NewCompressedWriter
+// when no CompressionOpts is supplied.
+var DefaultCompressionOpts = CompressionOpts{Level: flate.DefaultCompression}
+
+// updateFlateDict appends msg to dict and trims it back down to at most
+// maxFlateDict bytes, keeping only the most recent history — which is all
+// a flate dictionary can ever use.
+func updateFlateDict(dict, msg []byte) []byte {
+	dict = append(dict, msg...)
+	if len(dict) > maxFlateDict {
+		dict = dict[len(dict)-maxFlateDict:]
+	}
+	return dict
+}
+
+// compressedWriter is the underlying type that implements the WriteCloser
+// interface, compressing each message's payload with flate before handing
+// it to an ordinary msgio length-prefixed Writer.
+type compressedWriter struct {
+	inner WriteCloser
+	opts  CompressionOpts
+
+	pool sync.Pool // of *flate.Writer, used when !ContextTakeover
+	dict []byte    // trailing plaintext history, used when ContextTakeover
+	buf  bytes.Buffer
+}
+
+// NewCompressedWriter wraps an io.Writer with a msgio Writer that flate-
+// compresses each message before framing it, similar to gorilla/websocket's
+// permessage-deflate mode. By default a *flate.Writer is drawn from a
+// sync.Pool and reset per message, so steady-state writes allocate nothing
+// beyond the compressed output itself. An invalid CompressionOpts.Level is
+// reported immediately, rather than surfacing as a nil-pointer panic on the
+// first WriteMsg.
+func NewCompressedWriter(w io.Writer, opts ...CompressionOpts) (WriteCloser, error) {
+	o := DefaultCompressionOpts
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	// flate.NewWriter rejects invalid levels; run it once up front so a bad
+	// Level fails fast here instead of nil-panicking inside WriteMsg.
+	probe, err := flate.NewWriter(ioutil.Discard, o.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &compressedWriter{inner: NewWriter(w), opts: o}
+	cw.pool.New = func() interface{} {
+		zw, _ := flate.NewWriter(ioutil.Discard, o.Level)
+		return zw
+	}
+	cw.pool.Put(probe)
+	return cw, nil
+}
+
+func (s *compressedWriter) Write(msg []byte) error {
+	return s.WriteMsg(msg)
+}
+
+func (s *compressedWriter) WriteMsg(msg []byte) error {
+	s.buf.Reset()
+
+	if s.opts.ContextTakeover {
+		// flate.Writer has no way to reseed an existing dictionary short of
+		// Reset (which discards it), so each message gets a fresh Writer
+		// seeded with the trailing plaintext of everything written so far —
+		// the same compression benefit as carrying the dictionary forward.
+		fw, err := flate.NewWriterDict(&s.buf, s.opts.Level, s.dict)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(msg); err != nil {
+			return err
+		}
+		if err := fw.Flush(); err != nil {
+			return err
+		}
+		s.dict = updateFlateDict(s.dict, msg)
+	} else {
+		fw := s.pool.Get().(*flate.Writer)
+		defer s.pool.Put(fw)
+		fw.Reset(&s.buf)
+
+		if _, err := fw.Write(msg); err != nil {
+			return err
+		}
+		if err := fw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	data := bytes.TrimSuffix(s.buf.Bytes(), flateFinalBlock)
+	return s.inner.WriteMsg(data)
+}
+
+func (s *compressedWriter) Close() error {
+	return s.inner.Close()
+}
+
+// compressedReader is the underlying type that implements the ReadCloser
+// interface, inflating each message's payload after reading it off an
+// ordinary msgio length-prefixed Reader.
+type compressedReader struct {
+	inner     ReadCloser
+	opts      CompressionOpts
+	maxMsgLen int // 0 means no limit; guards against a zip-bomb payload
+
+	pool sync.Pool // of io.ReadCloser (flate.Resetter), reused every message
+	dict []byte    // trailing plaintext history, used when ContextTakeover
+}
+
+// NewCompressedReader wraps an io.Reader with a msgio Reader that inflates
+// each message after reading it, matching the framing produced by
+// NewCompressedWriter. By default a flate reader is drawn from a sync.Pool
+// and reset (via flate.Resetter) per message; with ContextTakeover, Reset
+// is still called every message (flate.Reader latches its first error
+// permanently, and every message induces one via the trailing marker), but
+// is seeded with the trailing plaintext of every message read so far as its
+// dictionary, so the carried-over context still improves compression.
+func NewCompressedReader(r io.Reader, opts ...CompressionOpts) ReadCloser {
+	return NewCompressedReaderSize(r, 0, opts...)
+}
+
+// NewCompressedReaderSize is like NewCompressedReader, but also rejects any
+// message whose *decompressed* size exceeds maxMsgLen (0 means no limit).
+// This also caps the compressed frame msgio reads off the wire, so a
+// hostile peer can neither send an oversized frame nor a small one that
+// inflates into an enormous allocation (a "zip bomb").
+func NewCompressedReaderSize(r io.Reader, maxMsgLen int, opts ...CompressionOpts) ReadCloser {
+	o := DefaultCompressionOpts
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	var readerOpts []ReaderOpt
+	if maxMsgLen > 0 {
+		readerOpts = append(readerOpts, WithMaxMessageSize(maxMsgLen))
+	}
+
+	cr := &compressedReader{
+		inner:     NewReaderWithPool(r, &multipool.ByteSlicePool, readerOpts...),
+		opts:      o,
+		maxMsgLen: maxMsgLen,
+	}
+	cr.pool.New = func() interface{} {
+		return flate.NewReader(bytes.NewReader(nil))
+	}
+	return cr
+}
+
+func (s *compressedReader) inflate() ([]byte, error) {
+	payload, err := s.inner.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	defer s.inner.ReleaseMsg(payload)
+
+	src := io.MultiReader(bytes.NewReader(payload), bytes.NewReader(flateFinalBlock))
+
+	fr := s.pool.Get().(io.ReadCloser)
+	defer s.pool.Put(fr)
+	// Reset always runs (even with ContextTakeover): compress/flate latches
+	// its first error permanently, and the trailing marker above guarantees
+	// one every message. Resetting is harmless to context takeover because
+	// Reset's second argument is exactly where the carried-over dictionary
+	// goes back in.
+	if err := fr.(flate.Resetter).Reset(src, s.dict); err != nil {
+		return nil, err
+	}
+
+	var src2 io.Reader = fr
+	if s.maxMsgLen > 0 {
+		src2 = io.LimitReader(fr, int64(s.maxMsgLen)+1)
+	}
+
+	var out bytes.Buffer
+	// flateFinalBlock is a sync-flush marker, not a real BFINAL block, so
+	// flate.Reader always reports io.ErrUnexpectedEOF once it's consumed —
+	// that's expected here, not corruption: the msgio frame underneath
+	// already guarantees payload is exactly the length it claims to be, so
+	// a real truncation surfaces as a short msgio read, not this.
+	if _, err := out.ReadFrom(src2); err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if s.maxMsgLen > 0 && out.Len() > s.maxMsgLen {
+		return nil, &ErrMsgTooLarge{Length: out.Len(), Max: s.maxMsgLen}
+	}
+	if s.opts.ContextTakeover {
+		s.dict = updateFlateDict(s.dict, out.Bytes())
+	}
+	return out.Bytes(), nil
+}
+
+func (s *compressedReader) Read(msg []byte) (int, error) {
+	data, err := s.inflate()
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(msg) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(msg, data), nil
+}
+
+func (s *compressedReader) ReadMsg() ([]byte, error) {
+	return s.inflate()
+}
+
+// ReleaseMsg is a no-op: the decompressed buffer returned by ReadMsg is not
+// drawn from a BufferPool, since its size is only known after inflating.
+func (s *compressedReader) ReleaseMsg(msg []byte) {}
+
+func (s *compressedReader) Close() error {
+	return s.inner.Close()
+}
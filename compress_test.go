@@ -0,0 +1,94 @@
+package msgio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressedReadWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCompressedWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewCompressedWriter: %v", err)
+	}
+	r := NewCompressedReader(&buf)
+
+	msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 1000)}
+	for _, msg := range msgs {
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatalf("WriteMsg: %v", err)
+		}
+	}
+
+	for _, want := range msgs {
+		got, err := r.ReadMsg()
+		if err != nil {
+			t.Fatalf("ReadMsg: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ReadMsg() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestCompressedContextTakeoverRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	opts := CompressionOpts{Level: DefaultCompressionOpts.Level, ContextTakeover: true}
+	w, err := NewCompressedWriter(&buf, opts)
+	if err != nil {
+		t.Fatalf("NewCompressedWriter: %v", err)
+	}
+	r := NewCompressedReader(&buf, opts)
+
+	msg := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 50)
+	for i := 0; i < 5; i++ {
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatalf("WriteMsg %d: %v", i, err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		got, err := r.ReadMsg()
+		if err != nil {
+			t.Fatalf("ReadMsg %d: %v", i, err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("ReadMsg(%d) mismatch", i)
+		}
+	}
+}
+
+// TestCompressedContextTakeoverShrinksFrames asserts that ContextTakeover
+// actually carries compression state across messages: once the dictionary
+// has seen a repeated payload, later occurrences of it should compress to
+// noticeably less than the first one did, not stay constant.
+func TestCompressedContextTakeoverShrinksFrames(t *testing.T) {
+	var buf bytes.Buffer
+	opts := CompressionOpts{Level: DefaultCompressionOpts.Level, ContextTakeover: true}
+	w, err := NewCompressedWriter(&buf, opts)
+	if err != nil {
+		t.Fatalf("NewCompressedWriter: %v", err)
+	}
+
+	msg := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 50)
+	var sizes []int
+	for i := 0; i < 5; i++ {
+		before := buf.Len()
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatalf("WriteMsg %d: %v", i, err)
+		}
+		sizes = append(sizes, buf.Len()-before)
+	}
+
+	if sizes[len(sizes)-1] >= sizes[0] {
+		t.Fatalf("frame sizes did not shrink with context takeover: %v", sizes)
+	}
+}
+
+func TestNewCompressedWriterRejectsInvalidLevel(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := NewCompressedWriter(&buf, CompressionOpts{Level: 42})
+	if err == nil {
+		t.Fatal("NewCompressedWriter with an invalid Level returned no error")
+	}
+}
@@ -2,7 +2,9 @@ package msgio
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
+	"net"
 
 	multipool "github.com/jbenet/go-msgio/multipool"
 )
@@ -65,6 +67,16 @@ type ReadWriteCloser interface {
 	io.Closer
 }
 
+// BatchWriter is implemented by Writers that can frame several messages
+// into a single underlying write, instead of one syscall (or more) per
+// message. Useful for callers, like bitswap flushing a want-list, that
+// produce many small messages at once.
+type BatchWriter interface {
+	// WriteMsgs frames each of msgs as its own length-prefixed message,
+	// written together in as few underlying writes as possible.
+	WriteMsgs(msgs [][]byte) error
+}
+
 // writer is the underlying type that implements the Writer interface.
 type writer struct {
 	W io.Writer
@@ -80,12 +92,31 @@ func (s *writer) Write(msg []byte) (err error) {
 	return s.WriteMsg(msg)
 }
 
+// WriteMsg writes the length prefix and the message in a single vectored
+// write (net.Buffers), rather than the two separate writes (and the
+// reflection-based binary.Write) this used to cost. When s.W is a
+// *net.TCPConn (or anything else net.Buffers knows how to batch), this is a
+// single syscall; otherwise net.Buffers falls back to writing each slice in
+// turn.
 func (s *writer) WriteMsg(msg []byte) (err error) {
-	length := uint32(len(msg))
-	if err := binary.Write(s.W, NBO, &length); err != nil {
-		return err
+	var lbuf [lengthSize]byte
+	NBO.PutUint32(lbuf[:], uint32(len(msg)))
+	bufs := net.Buffers{lbuf[:], msg}
+	_, err = bufs.WriteTo(s.W)
+	return err
+}
+
+// WriteMsgs frames every message in msgs and writes them all via a single
+// net.Buffers, so when s.W supports vectored writes N messages cost a single
+// underlying write; otherwise net.Buffers writes each slice in turn.
+func (s *writer) WriteMsgs(msgs [][]byte) error {
+	lbufs := make([][lengthSize]byte, len(msgs))
+	bufs := make(net.Buffers, 0, len(msgs)*2)
+	for i, msg := range msgs {
+		NBO.PutUint32(lbufs[i][:], uint32(len(msg)))
+		bufs = append(bufs, lbufs[i][:], msg)
 	}
-	_, err = s.W.Write(msg)
+	_, err := bufs.WriteTo(s.W)
 	return err
 }
 
@@ -96,13 +127,47 @@ func (s *writer) Close() error {
 	return nil
 }
 
+// ErrMsgTooLarge is returned by Read/ReadMsg when a peer announces a
+// message longer than the reader's configured maximum. The payload bytes
+// are left unread on the underlying io.Reader, so the caller should treat
+// the connection as unsynchronized (and typically close it) rather than
+// attempt to keep reading.
+type ErrMsgTooLarge struct {
+	Length int
+	Max    int
+}
+
+func (e *ErrMsgTooLarge) Error() string {
+	return fmt.Sprintf("msgio: message size (%d) exceeds maximum (%d)", e.Length, e.Max)
+}
+
 // reader is the underlying type that implements the Reader interface.
 type reader struct {
 	R io.Reader
 
-	lbuf []byte
-	next int
-	pool *multipool.Pool
+	lbuf      []byte
+	next      int
+	pool      BufferPool
+	maxMsgLen int // 0 means no limit beyond the pool's own ceiling
+}
+
+// ReaderOpt configures a reader constructed via NewReaderWithPool or
+// NewReaderSize.
+type ReaderOpt func(*readerOpts)
+
+type readerOpts struct {
+	maxMsgLen int
+}
+
+// WithMaxMessageSize caps the length a reader will accept for a single
+// message at size bytes. Once the length prefix announces more than size,
+// Read/ReadMsg return an *ErrMsgTooLarge instead of attempting to read (or
+// pool) the payload. This is the standard guard against an OOM from a
+// hostile or buggy peer on an untrusted socket.
+func WithMaxMessageSize(size int) ReaderOpt {
+	return func(o *readerOpts) {
+		o.maxMsgLen = size
+	}
 }
 
 // NewReader wraps an io.Reader with a msgio framed reader. The msgio.Reader
@@ -112,14 +177,21 @@ func NewReader(r io.Reader) ReadCloser {
 	return NewReaderWithPool(r, &multipool.ByteSlicePool)
 }
 
+// NewReaderSize is like NewReader, but rejects any message whose announced
+// length exceeds maxMsgLen. See WithMaxMessageSize.
+func NewReaderSize(r io.Reader, maxMsgLen int) ReadCloser {
+	return NewReaderWithPool(r, &multipool.ByteSlicePool, WithMaxMessageSize(maxMsgLen))
+}
+
 // NewReaderWithPool wraps an io.Reader with a msgio framed reader. The msgio.Reader
 // will read whole messages at a time (using the length). Assumes an equivalent
-// writer on the other side.  It uses a given multipool.Pool
-func NewReaderWithPool(r io.Reader, p *multipool.Pool) ReadCloser {
+// writer on the other side.  It uses a given multipool.Pool, plus any
+// ReaderOpts (e.g. WithMaxMessageSize).
+func NewReaderWithPool(r io.Reader, p *multipool.Pool, opts ...ReaderOpt) ReadCloser {
 	if p == nil {
 		panic("nil pool")
 	}
-	return &reader{r, make([]byte, lengthSize), -1, p}
+	return NewReaderWithBufferPool(r, multipoolAdapter{p}, opts...)
 }
 
 // nextMsgLen reads the length of the next msg into s.lbuf, and returns it.
@@ -132,6 +204,9 @@ func (s *reader) nextMsgLen() (int, error) {
 		}
 		s.next = int(NBO.Uint32(s.lbuf))
 	}
+	if s.maxMsgLen > 0 && s.next > s.maxMsgLen {
+		return 0, &ErrMsgTooLarge{Length: s.next, Max: s.maxMsgLen}
+	}
 	return s.next, nil
 }
 
@@ -155,22 +230,18 @@ func (s *reader) ReadMsg() ([]byte, error) {
 		return nil, err
 	}
 
-	msgb := s.pool.Get(uint32(length))
-	if msgb == nil {
+	msg := s.pool.Get(length)
+	if msg == nil {
 		return nil, io.ErrShortBuffer
 	}
-	msg := msgb.([]byte)[:length]
+	msg = msg[:length]
 	_, err = io.ReadFull(s.R, msg)
 	s.next = -1 // signal we've consumed this msg
 	return msg, err
 }
 
 func (s *reader) ReleaseMsg(msg []byte) {
-	c := cap(msg)
-	if c > multipool.MaxLength {
-		c = multipool.MaxLength
-	}
-	s.pool.Put(uint32(c), msg)
+	s.pool.Put(msg)
 }
 
 func (s *reader) Close() error {
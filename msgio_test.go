@@ -0,0 +1,104 @@
+package msgio
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestNewReaderSizeRejectsOversizedLength(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteMsg(bytes.Repeat([]byte("x"), 100)); err != nil {
+		t.Fatalf("WriteMsg: %v", err)
+	}
+
+	r := NewReaderSize(&buf, 10)
+	_, err := r.ReadMsg()
+	var tooLarge *ErrMsgTooLarge
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("ReadMsg() error = %v, want *ErrMsgTooLarge", err)
+	}
+	if tooLarge.Length != 100 || tooLarge.Max != 10 {
+		t.Fatalf("ReadMsg() error = %+v, want Length=100 Max=10", tooLarge)
+	}
+}
+
+// syncPoolBuffer is a minimal custom BufferPool backed by a sync.Pool,
+// standing in for something like go-buffer-pool's *pool.Pool -- the whole
+// point of the BufferPool interface is that callers can plug in a pool like
+// this instead of msgio's own multipool.Pool.
+type syncPoolBuffer struct {
+	pool sync.Pool
+	gets int
+	puts int
+}
+
+func (p *syncPoolBuffer) Get(length int) []byte {
+	p.gets++
+	b, _ := p.pool.Get().([]byte)
+	if b == nil || cap(b) < length {
+		b = make([]byte, length)
+	}
+	return b[:length]
+}
+
+func (p *syncPoolBuffer) Put(b []byte) {
+	p.puts++
+	p.pool.Put(b)
+}
+
+func TestReaderWithCustomBufferPool(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 1000)}
+	for _, msg := range msgs {
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatalf("WriteMsg: %v", err)
+		}
+	}
+
+	pool := &syncPoolBuffer{}
+	r := NewReaderWithBufferPool(&buf, pool)
+	for _, want := range msgs {
+		got, err := r.ReadMsg()
+		if err != nil {
+			t.Fatalf("ReadMsg: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ReadMsg() = %q, want %q", got, want)
+		}
+		r.ReleaseMsg(got)
+	}
+
+	if pool.gets != len(msgs) {
+		t.Fatalf("pool.Get called %d times, want %d", pool.gets, len(msgs))
+	}
+	if pool.puts != len(msgs) {
+		t.Fatalf("pool.Put called %d times, want %d", pool.puts, len(msgs))
+	}
+}
+
+func TestWriteMsgsRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf).(BatchWriter)
+	r := NewReader(&buf)
+
+	msgs := [][]byte{[]byte("first"), {}, bytes.Repeat([]byte("x"), 1000), []byte("last")}
+	if err := w.WriteMsgs(msgs); err != nil {
+		t.Fatalf("WriteMsgs: %v", err)
+	}
+
+	for _, want := range msgs {
+		got, err := r.ReadMsg()
+		if err != nil {
+			t.Fatalf("ReadMsg: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ReadMsg() = %q, want %q", got, want)
+		}
+		r.ReleaseMsg(got)
+	}
+}
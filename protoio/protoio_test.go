@@ -0,0 +1,69 @@
+package protoio
+
+import (
+	"bytes"
+	"testing"
+)
+
+// plainMessage is a proto.Message that does NOT implement protoio.Marshaler
+// (no MarshalTo), exercising WriteMsg's proto.Marshal fallback path. It
+// implements gogo's own Marshal/Unmarshal compatibility interfaces, which is
+// as close as a hand-written (non-generated) type gets to a real
+// protoc-gogo message.
+type plainMessage struct {
+	Data string
+}
+
+func (m *plainMessage) Reset()         { *m = plainMessage{} }
+func (m *plainMessage) String() string { return m.Data }
+func (m *plainMessage) ProtoMessage()  {}
+
+func (m *plainMessage) Marshal() ([]byte, error) {
+	return []byte(m.Data), nil
+}
+
+func (m *plainMessage) Unmarshal(data []byte) error {
+	m.Data = string(data)
+	return nil
+}
+
+func TestWriterFallsBackToProtoMarshal(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewProtobufWriter(&buf)
+
+	if err := w.WriteMsg(&plainMessage{Data: "hello"}); err != nil {
+		t.Fatalf("WriteMsg: %v", err)
+	}
+
+	r := NewProtobufReader(&buf)
+	var got plainMessage
+	if err := r.ReadMsg(&got); err != nil {
+		t.Fatalf("ReadMsg: %v", err)
+	}
+	if got.Data != "hello" {
+		t.Fatalf("ReadMsg() = %q, want %q", got.Data, "hello")
+	}
+}
+
+func TestProtobufReadWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewProtobufWriter(&buf)
+	r := NewProtobufReader(&buf)
+
+	msgs := []string{"first", "", "a longer third message"}
+	for _, s := range msgs {
+		if err := w.WriteMsg(&plainMessage{Data: s}); err != nil {
+			t.Fatalf("WriteMsg(%q): %v", s, err)
+		}
+	}
+
+	for _, want := range msgs {
+		var got plainMessage
+		if err := r.ReadMsg(&got); err != nil {
+			t.Fatalf("ReadMsg: %v", err)
+		}
+		if got.Data != want {
+			t.Fatalf("ReadMsg() = %q, want %q", got.Data, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package protoio
+
+import (
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+
+	msgio "github.com/jbenet/go-msgio"
+)
+
+// ReadCloser is the protoio equivalent of msgio.ReadCloser: it reads
+// msgio-framed buffers and unmarshals them into a caller-supplied
+// proto.Message.
+type ReadCloser interface {
+	ReadMsg(msg proto.Message) error
+	io.Closer
+}
+
+// reader is the underlying type that implements the ReadCloser interface.
+type reader struct {
+	r msgio.ReadCloser
+}
+
+// NewProtobufReader wraps an io.Reader with a msgio-framed protobuf reader.
+// The payload buffer for each message is drawn from msgio's buffer pool and
+// released back to it as soon as it has been unmarshaled.
+//
+// The underlying msgio.Reader this constructs has no maximum message size
+// (see msgio.WithMaxMessageSize), so on an untrusted socket prefer
+// NewProtobufReaderWithMsgioReader with a reader built via
+// msgio.NewReaderSize or msgio.NewReaderWithPool(..., msgio.WithMaxMessageSize(n)).
+func NewProtobufReader(r io.Reader) ReadCloser {
+	return &reader{msgio.NewReader(r)}
+}
+
+// NewProtobufReaderWithMsgioReader is like NewProtobufReader, but takes an
+// already-constructed msgio.ReadCloser instead of building an unbounded one
+// internally — the hook for passing in one built with
+// msgio.WithMaxMessageSize, a custom msgio.BufferPool, or varint framing.
+func NewProtobufReaderWithMsgioReader(r msgio.ReadCloser) ReadCloser {
+	return &reader{r}
+}
+
+func (r *reader) ReadMsg(msg proto.Message) error {
+	data, err := r.r.ReadMsg()
+	if err != nil {
+		return err
+	}
+	err = proto.Unmarshal(data, msg)
+	r.r.ReleaseMsg(data)
+	return err
+}
+
+func (r *reader) Close() error {
+	return r.r.Close()
+}
@@ -0,0 +1,64 @@
+package protoio
+
+import (
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+
+	msgio "github.com/jbenet/go-msgio"
+)
+
+// WriteCloser is the protoio equivalent of msgio.WriteCloser: it writes
+// proto.Message values instead of raw byte slices, framing each one with
+// msgio's length prefix.
+type WriteCloser interface {
+	WriteMsg(proto.Message) error
+	io.Closer
+}
+
+// Marshaler is implemented by generated protobuf types (gogoproto's
+// marshaler plugin) that can marshal themselves into a caller-supplied
+// buffer without an intermediate allocation.
+type Marshaler interface {
+	MarshalTo(data []byte) (n int, err error)
+}
+
+// writer is the underlying type that implements the WriteCloser interface.
+type writer struct {
+	w   msgio.WriteCloser
+	buf []byte
+}
+
+// NewProtobufWriter wraps an io.Writer with a msgio-framed protobuf writer.
+// Messages that implement Marshaler are marshaled into a scratch buffer that
+// is reused (and grown as needed) across calls, sized up front via
+// proto.Size to avoid the marshal-then-copy path of proto.Marshal; any other
+// message is marshaled with proto.Marshal.
+func NewProtobufWriter(w io.Writer) WriteCloser {
+	return &writer{w: msgio.NewWriter(w)}
+}
+
+func (w *writer) WriteMsg(msg proto.Message) error {
+	if m, ok := msg.(Marshaler); ok {
+		n := proto.Size(msg)
+		if cap(w.buf) < n {
+			w.buf = make([]byte, n)
+		} else {
+			w.buf = w.buf[:n]
+		}
+		if _, err := m.MarshalTo(w.buf); err != nil {
+			return err
+		}
+		return w.w.WriteMsg(w.buf)
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return w.w.WriteMsg(data)
+}
+
+func (w *writer) Close() error {
+	return w.w.Close()
+}
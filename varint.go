@@ -0,0 +1,196 @@
+package msgio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+
+	multipool "github.com/jbenet/go-msgio/multipool"
+)
+
+// maxVarintBytes is the largest number of bytes a binary.Uvarint-style
+// varint can occupy. Beyond this, the encoding is malformed (or hostile).
+const maxVarintBytes = binary.MaxVarintLen64
+
+// errOverflow is returned when a length-prefix varint takes more than
+// maxVarintBytes bytes to decode, overflows a 64-bit integer, or decodes to
+// a length above math.MaxUint32.
+var errOverflow = errors.New("msgio: varint length prefix overflows")
+
+// errSmallBuffer is returned from Read when the buffer passed in is too
+// small for the incoming message, and from ReadMsg when the backing pool
+// has nothing to offer.
+var errSmallBuffer = errors.New("msgio: buffer too small")
+
+// varintWriter is the underlying type that implements the Writer interface,
+// but writes the length-prefix as a protobuf-style unsigned varint rather
+// than a fixed-size big-endian uint32.
+type varintWriter struct {
+	W io.Writer
+}
+
+// NewVarintWriter wraps an io.Writer with a varint-length-prefixed msgio
+// writer, compatible with the framing used by gogo protobuf's
+// io.NewDelimitedWriter and Tendermint's protoio.
+func NewVarintWriter(w io.Writer) WriteCloser {
+	return &varintWriter{w}
+}
+
+func (s *varintWriter) Write(msg []byte) (err error) {
+	return s.WriteMsg(msg)
+}
+
+func (s *varintWriter) WriteMsg(msg []byte) (err error) {
+	var lbuf [maxVarintBytes]byte
+	n := binary.PutUvarint(lbuf[:], uint64(len(msg)))
+	bufs := net.Buffers{lbuf[:n], msg}
+	_, err = bufs.WriteTo(s.W)
+	return err
+}
+
+func (s *varintWriter) Close() error {
+	if c, ok := s.W.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// varintReader is the underlying type that implements the Reader interface
+// for varint-length-prefixed messages.
+type varintReader struct {
+	R io.Reader
+
+	next      int
+	pool      BufferPool
+	maxMsgLen int // 0 means no limit beyond what fits a uint32
+}
+
+// NewVarintReader wraps an io.Reader with a varint-length-prefixed msgio
+// reader, compatible with the framing used by gogo protobuf's
+// io.NewDelimitedReader and Tendermint's protoio. Assumes an equivalent
+// writer on the other side.
+func NewVarintReader(r io.Reader) ReadCloser {
+	return NewVarintReaderWithPool(r, &multipool.ByteSlicePool)
+}
+
+// NewVarintReaderSize is like NewVarintReader, but rejects any message
+// whose announced length exceeds maxMsgLen. See WithMaxMessageSize; this is
+// just as essential here as it is for NewReaderSize; a varint length prefix
+// can otherwise announce anything up to MaxUint32.
+func NewVarintReaderSize(r io.Reader, maxMsgLen int) ReadCloser {
+	return NewVarintReaderWithPool(r, &multipool.ByteSlicePool, WithMaxMessageSize(maxMsgLen))
+}
+
+// NewVarintReaderWithPool is like NewVarintReader, but uses a given
+// multipool.Pool for buffer reuse, plus any ReaderOpts (e.g.
+// WithMaxMessageSize).
+func NewVarintReaderWithPool(r io.Reader, p *multipool.Pool, opts ...ReaderOpt) ReadCloser {
+	if p == nil {
+		panic("nil pool")
+	}
+	var o readerOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &varintReader{r, -1, multipoolAdapter{p}, o.maxMsgLen}
+}
+
+// nextMsgLen reads the varint length of the next msg, byte by byte, bounded
+// to maxVarintBytes so a hostile peer cannot force an unbounded read.
+// WARNING: like ReadMsg, nextMsgLen is destructive. It reads from the
+// internal reader.
+func (s *varintReader) nextMsgLen() (int, error) {
+	if s.next == -1 {
+		var buf [1]byte
+		var x uint64
+		var sh uint
+		found := false
+		for i := 0; i < maxVarintBytes; i++ {
+			if _, err := io.ReadFull(s.R, buf[:]); err != nil {
+				return 0, err
+			}
+			b := buf[0]
+			if b < 0x80 {
+				// The 10th byte can only ever contribute bit 63; anything
+				// else overflows a 64-bit integer, exactly like
+				// encoding/binary.Uvarint detects on its last byte.
+				if i == maxVarintBytes-1 && b > 1 {
+					return 0, errOverflow
+				}
+				x |= uint64(b) << sh
+				found = true
+				break
+			}
+			x |= uint64(b&0x7f) << sh
+			sh += 7
+		}
+		if !found {
+			return 0, errOverflow
+		}
+		// Cap decoded lengths to what the fixed-header format can express
+		// too, so a malformed or hostile varint can't slip a value through
+		// that doesn't fit back into an int (e.g. 1<<63, which becomes
+		// negative once converted below).
+		if x > math.MaxUint32 {
+			return 0, errOverflow
+		}
+		s.next = int(x)
+	}
+	if s.maxMsgLen > 0 && s.next > s.maxMsgLen {
+		return 0, &ErrMsgTooLarge{Length: s.next, Max: s.maxMsgLen}
+	}
+	return s.next, nil
+}
+
+func (s *varintReader) Read(msg []byte) (int, error) {
+	length, err := s.nextMsgLen()
+	if err != nil {
+		return 0, err
+	}
+
+	if length > len(msg) {
+		return 0, errSmallBuffer
+	}
+	_, err = io.ReadFull(s.R, msg[:length])
+	s.next = -1 // signal we've consumed this msg
+	return length, err
+}
+
+func (s *varintReader) ReadMsg() ([]byte, error) {
+	length, err := s.nextMsgLen()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := s.pool.Get(length)
+	if msg == nil {
+		return nil, errSmallBuffer
+	}
+	msg = msg[:length]
+	_, err = io.ReadFull(s.R, msg)
+	s.next = -1 // signal we've consumed this msg
+	return msg, err
+}
+
+func (s *varintReader) ReleaseMsg(msg []byte) {
+	s.pool.Put(msg)
+}
+
+func (s *varintReader) Close() error {
+	if c, ok := s.R.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewVarintReadWriter wraps an io.ReadWriter with a msgio.ReadWriter that
+// frames messages with a varint length prefix, compatible with the framing
+// used by gogo protobuf's writeDelimited/parseDelimited.
+func NewVarintReadWriter(rw io.ReadWriter) ReadWriter {
+	return &readWriter{
+		Reader: NewVarintReader(rw),
+		Writer: NewVarintWriter(rw),
+	}
+}
@@ -0,0 +1,85 @@
+package msgio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestVarintReaderRejectsOverflowingLength(t *testing.T) {
+	// 9 continuation-bit bytes followed by a 10th, final byte whose value (2) is
+	// large enough to overflow a 64-bit integer once shifted into the high
+	// bits — the same case encoding/binary.Uvarint rejects on its own.
+	frame := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02}
+	r := NewVarintReader(bytes.NewReader(frame))
+
+	if _, err := r.ReadMsg(); !errors.Is(err, errOverflow) {
+		t.Fatalf("ReadMsg() error = %v, want errOverflow", err)
+	}
+}
+
+func TestVarintReaderRejectsLengthAboveUint32(t *testing.T) {
+	// Encodes 1<<63, which decodes cleanly as a uint64 but used to become a
+	// negative int once cast, panicking Read/ReadMsg instead of being
+	// rejected up front.
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], 1<<63)
+	r := NewVarintReader(bytes.NewReader(buf[:n]))
+
+	if _, err := r.ReadMsg(); !errors.Is(err, errOverflow) {
+		t.Fatalf("ReadMsg() error = %v, want errOverflow", err)
+	}
+}
+
+func TestVarintReaderTruncatedLength(t *testing.T) {
+	// A continuation byte with nothing after it.
+	r := NewVarintReader(bytes.NewReader([]byte{0x80}))
+
+	_, err := r.ReadMsg()
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		t.Fatalf("ReadMsg() error = %v, want io.EOF or io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestVarintReaderSizeRejectsOversizedLength(t *testing.T) {
+	var buf bytes.Buffer
+	rw := NewVarintReadWriter(&buf)
+	if err := rw.WriteMsg(bytes.Repeat([]byte("x"), 100)); err != nil {
+		t.Fatalf("WriteMsg: %v", err)
+	}
+
+	r := NewVarintReaderSize(&buf, 10)
+	_, err := r.ReadMsg()
+	var tooLarge *ErrMsgTooLarge
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("ReadMsg() error = %v, want *ErrMsgTooLarge", err)
+	}
+	if tooLarge.Length != 100 || tooLarge.Max != 10 {
+		t.Fatalf("ReadMsg() error = %+v, want Length=100 Max=10", tooLarge)
+	}
+}
+
+func TestVarintReadWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	rw := NewVarintReadWriter(&buf)
+
+	msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 1000)}
+	for _, msg := range msgs {
+		if err := rw.WriteMsg(msg); err != nil {
+			t.Fatalf("WriteMsg: %v", err)
+		}
+	}
+
+	for _, want := range msgs {
+		got, err := rw.ReadMsg()
+		if err != nil {
+			t.Fatalf("ReadMsg: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ReadMsg() = %q, want %q", got, want)
+		}
+		rw.ReleaseMsg(got)
+	}
+}